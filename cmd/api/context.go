@@ -9,7 +9,10 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey      = contextKey("user")
+	requestIdContextKey = contextKey("request_id")
+)
 
 func (bknd *backend) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -23,3 +26,13 @@ func (bknd *backend) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (bknd *backend) contextSetRequestId(r *http.Request, requestId string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIdContextKey, requestId)
+	return r.WithContext(ctx)
+}
+
+func (bknd *backend) contextGetRequestId(r *http.Request) string {
+	requestId, _ := r.Context().Value(requestIdContextKey).(string)
+	return requestId
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (bknd *backend) logError(r *http.Request, err error) {
 	var (
-		method = r.Method
-		uri    = r.URL.RequestURI()
+		method    = r.Method
+		uri       = r.URL.RequestURI()
+		requestId = bknd.contextGetRequestId(r)
 	)
-	bknd.logger.Error(err.Error(), "method", method, "uri", uri)
+	bknd.logger.Error(err.Error(), "request_id", requestId, "method", method, "uri", uri)
 }
 
 func (bknd *backend) errorResponseJSON(w http.ResponseWriter, r *http.Request, status int, msg any) {
@@ -45,9 +49,18 @@ func (bknd *backend) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 }
 
 func (bknd *backend) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errUnsupportedMediaType) {
+		bknd.unsupportedMediaTypeResponse(w, r)
+		return
+	}
 	bknd.errorResponseJSON(w, r, http.StatusBadRequest, err.Error())
 }
 
+func (bknd *backend) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	msg := fmt.Sprintf("unsupported Content-Type %q, %s", r.Header.Get("Content-Type"), errUnsupportedMediaType)
+	bknd.errorResponseJSON(w, r, http.StatusUnsupportedMediaType, msg)
+}
+
 func (bknd *backend) failedValidationResponse(w http.ResponseWriter, r *http.Request,
 	errs map[string]string,
 ) {
@@ -59,7 +72,8 @@ func (bknd *backend) editConflictResponse(w http.ResponseWriter, r *http.Request
 	bknd.errorResponseJSON(w, r, http.StatusConflict, msg)
 }
 
-func (bknd *backend) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+func (bknd *backend) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
 	msg := "rate limit exceeded, please try after a few seconds"
 	bknd.errorResponseJSON(w, r, http.StatusTooManyRequests, msg)
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"FernArchive/internal/data"
+	"FernArchive/internal/validator"
+)
+
+func (bknd *backend) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	genres, err := bknd.models.Genres.GetAll(ctx)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	vldtr := validator.NewValidator()
+
+	if data.ValidateGenre(vldtr, name); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	err = bknd.models.Genres.Insert(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			vldtr.AddError("name", "genre already exists")
+			bknd.failedValidationResponse(w, r, vldtr.Errors)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusCreated, envelope{"genre": name}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
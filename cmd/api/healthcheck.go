@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"time"
 )
 
 func (bknd *backend) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,3 +18,19 @@ func (bknd *backend) healthcheckHandler(w http.ResponseWriter, r *http.Request)
 		bknd.serverErrorResponse(w, r, err)
 	}
 }
+
+func (bknd *backend) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	status, code := "ready", http.StatusOK
+
+	if err := bknd.db.PingContext(ctx); err != nil {
+		bknd.logError(r, err)
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	err := bknd.writeJSON(w, code, envelope{"status": status}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
+	"FernArchive/internal/data"
 	"FernArchive/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
@@ -17,6 +22,8 @@ import (
 
 type envelope map[string]any
 
+var errUnsupportedMediaType = errors.New("body must be sent with Content-Type application/json")
+
 func (bknd *backend) readIdParam(r *http.Request) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
@@ -26,6 +33,26 @@ func (bknd *backend) readIdParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// weakETag identifies a representation of the movie. Ratings are part of the
+// body but do not bump the version, so their aggregates are folded in as well.
+func (bknd *backend) weakETag(movie *data.Movie) string {
+	return fmt.Sprintf(`W/"%d-%d-%d-%.4f"`, movie.Id, movie.Version, movie.RatingCount, movie.AverageRating)
+}
+
+func (bknd *backend) etagMatches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
 func (bknd *backend) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	jsn, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -44,11 +71,50 @@ func (bknd *backend) writeJSON(w http.ResponseWriter, status int, data envelope,
 	return nil
 }
 
+func (bknd *backend) writeMoviesCSV(w http.ResponseWriter, movies []*data.Movie) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="movies.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	csvw := csv.NewWriter(w)
+	err := csvw.Write([]string{"id", "title", "year", "runtime", "genres"})
+	if err != nil {
+		return err
+	}
+	for _, movie := range movies {
+		err = csvw.Write([]string{
+			strconv.FormatInt(movie.Id, 10),
+			movie.Title,
+			strconv.Itoa(int(movie.Year)),
+			strconv.Itoa(int(movie.Runtime)),
+			strings.Join(movie.Genres, "|"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
+
 func (bknd *backend) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	var maxBytes int64 = 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBytes))
 
-	dec := json.NewDecoder(r.Body)
+	// An empty body is left to the decoder so it keeps reporting "body must not be empty".
+	// Chunked bodies have no length, so peek to find out whether anything was sent.
+	hasBody := r.ContentLength > 0
+	if r.ContentLength == -1 {
+		_, err := body.Peek(1)
+		hasBody = err == nil
+	}
+	if hasBody {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			return errUnsupportedMediaType
+		}
+	}
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 
 	err := dec.Decode(dst)
@@ -93,6 +159,19 @@ func (bknd *backend) decodeJSONError(err error) error {
 	}
 }
 
+func (bknd *backend) absoluteURL(r *http.Request, key, value string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	qs := r.URL.Query()
+	if key != "" {
+		qs.Set(key, value)
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: qs.Encode()}
+	return u.String()
+}
+
 func (bknd *backend) readCSV(qs url.Values, key string, defaultValue []string) []string {
 	csv := qs.Get(key)
 	if csv == "" {
@@ -122,6 +201,14 @@ func (bknd *backend) readInt(qs url.Values, key string, defaultValue int, vldtr
 	return intgr
 }
 
+// currentUser loads the full record of the authenticated user. Users resolved from a
+// JWT only carry their claims, so handlers that need the password hash or profile
+// fields must go through here. It also refuses JWTs revoked since they were issued.
+func (bknd *backend) currentUser(ctx context.Context, r *http.Request) (*data.User, error) {
+	user := bknd.contextGetUser(r)
+	return bknd.models.Users.GetForTokenVersion(ctx, user.Id, user.TokenVersion)
+}
+
 func (bknd *backend) background(fn func()) {
 	bknd.wtgrp.Add(1)
 	go func() {
@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,37 +32,63 @@ type config struct {
 		maxOpenConns int
 		maxIdleTime  time.Duration
 		maxIdleConns int
+		queryTimeout time.Duration
 	}
 	limiter struct {
 		rps     float64
 		burst   int
 		enabled bool
+		perUser bool
 	}
 	smtp struct {
-		host     string
-		port     int
-		username string
-		password string
-		sender   string
+		host       string
+		port       int
+		username   string
+		password   string
+		sender     string
+		retries    int
+		retryDelay time.Duration
 	}
 	cors struct {
 		allowedOrigins []string
 	}
+	log struct {
+		format string
+		level  string
+	}
+	jwt struct {
+		secret string
+	}
+	tls struct {
+		certFile string
+		keyFile  string
+	}
+	tokenCleanupInterval time.Duration
+	bcryptCost           int
+	posterDir            string
 }
 
 type backend struct {
-	logger *slog.Logger
-	config config
-	models data.Models
-	mailer mailer.Mailer
-	wtgrp  sync.WaitGroup
+	logger  *slog.Logger
+	config  config
+	db      *sql.DB
+	models  data.Models
+	mailer  mailer.Mailer
+	limiter *clientLimiter
+	wtgrp   sync.WaitGroup
 }
 
 func main() {
 	var cfg config
-	runClFlags(&cfg)
-
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	if err := runClFlags(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.Error(err.Error())
@@ -77,9 +106,10 @@ func main() {
 	bknd := &backend{
 		logger: logger,
 		config: cfg,
+		db:     db,
 		models: data.NewModels(db),
-		mailer: mailer.NewMailer(cfg.smtp.host, cfg.smtp.port,
-			cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.NewMailer(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password,
+			cfg.smtp.sender, cfg.smtp.retries, cfg.smtp.retryDelay),
 	}
 	err = bknd.serve()
 	if err != nil {
@@ -88,6 +118,23 @@ func main() {
 	}
 }
 
+func newLogger(cfg config) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.log.level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", cfg.log.level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch cfg.log.format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stdout, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", cfg.log.format)
+	}
+}
+
 func initializeCustomMetrics(db *sql.DB) {
 	expvar.NewString("version").Set(version)
 	expvar.Publish(
@@ -98,7 +145,9 @@ func initializeCustomMetrics(db *sql.DB) {
 		"timestamp", expvar.Func(func() any { return time.Now().Unix() }))
 }
 
-func runClFlags(cfg *config) {
+func runClFlags(cfg *config) error {
+	configPath := flag.String("config", "", "Path to a JSON config file keyed by flag name")
+
 	flag.StringVar(&cfg.env, "env", "dev", "Environment (dev, staging, prod)")
 	flag.IntVar(&cfg.port, "port", 4000, "API server port")
 
@@ -110,17 +159,38 @@ func runClFlags(cfg *config) {
 	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "DB max open connections")
 	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "DB max idle connections")
 	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 15*time.Minute, "DB max idle time")
+	flag.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", 3*time.Second, "DB query timeout per request")
 
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Limiter max requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 5, "Limiter max burst requests")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiting")
+	flag.BoolVar(&cfg.limiter.perUser, "limiter-per-user", false, "Rate limit authenticated requests per user")
 
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
-	flag.StringVar(&cfg.smtp.username, "smtp-username", "1702867f97eaf8", "SMTP username")
-	flag.StringVar(&cfg.smtp.password, "smtp-password", "84bdbfed10e5d6", "SMTP password")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender",
 		"FernArchive <parthsrivastav.00@gmail.com>", "SMTP sender")
+	flag.IntVar(&cfg.smtp.retries, "smtp-retries", 2, "SMTP retries after a failed send")
+	flag.DurationVar(&cfg.smtp.retryDelay, "smtp-retry-delay", time.Second, "SMTP initial retry delay (doubles per retry)")
+
+	flag.StringVar(&cfg.tls.certFile, "tls-cert", "", "TLS certificate file (enables HTTPS with -tls-key)")
+	flag.StringVar(&cfg.tls.keyFile, "tls-key", "", "TLS private key file (enables HTTPS with -tls-cert)")
+
+	flag.DurationVar(&cfg.tokenCleanupInterval, "token-cleanup-interval", time.Hour,
+		"Interval between purges of expired tokens (0 disables)")
+
+	flag.IntVar(&cfg.bcryptCost, "bcrypt-cost", 12, "Bcrypt cost factor for password hashes (4-31)")
+	flag.StringVar(&cfg.posterDir, "poster-dir", "./posters", "Directory where movie poster images are stored")
+
+	// JWTs are verified without a database lookup, so revoking them (password change
+	// or reset, account deletion) only fully applies once they expire, after an hour.
+	// Handlers that load the full user refuse revoked JWTs straight away.
+	flag.StringVar(&cfg.jwt.secret, "jwt-secret", "", "HMAC secret for signing JWT auth tokens (empty disables JWTs)")
+
+	flag.StringVar(&cfg.log.format, "log-format", "text", "Log format (text|json)")
+	flag.StringVar(&cfg.log.level, "log-level", "info", "Log level (debug|info|warn|error)")
 
 	defaultOrigins := []string{"http://localhost:9000",
 		"http://localhost:9003",
@@ -140,9 +210,79 @@ func runClFlags(cfg *config) {
 		fmt.Printf("Version:\t%s\n", version)
 		os.Exit(0)
 	}
+	if *configPath == "" {
+		*configPath = os.Getenv(envName("config"))
+	}
+	if err := applyEnvAndFile(*configPath); err != nil {
+		return err
+	}
+	if err := data.SetBcryptCost(cfg.bcryptCost); err != nil {
+		return err
+	}
+	if (cfg.tls.certFile == "") != (cfg.tls.keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be provided together")
+	}
 	if len(cfg.cors.allowedOrigins) == 0 {
 		cfg.cors.allowedOrigins = defaultOrigins
 	}
+	return nil
+}
+
+func envName(flagName string) string {
+	return "FERN_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func applyEnvAndFile(configPath string) error {
+	fileValues := make(map[string]any)
+	if configPath != "" {
+		file, err := os.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err = json.Unmarshal(file, &fileValues); err != nil {
+			return fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "config" || f.Name == "version" {
+			return
+		}
+		if val, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := flag.Set(f.Name, val); setErr != nil {
+				err = fmt.Errorf("invalid value for %s: %w", envName(f.Name), setErr)
+			}
+			return
+		}
+		if raw, ok := fileValues[f.Name]; ok {
+			if setErr := flag.Set(f.Name, configFileValue(raw)); setErr != nil {
+				err = fmt.Errorf("invalid value for %q in config file: %w", f.Name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+func configFileValue(raw any) string {
+	switch val := raw.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case []any:
+		parts := make([]string, 0, len(val))
+		for _, part := range val {
+			parts = append(parts, configFileValue(part))
+		}
+		return strings.Join(parts, " ")
+	default:
+		return fmt.Sprintf("%v", val)
+	}
 }
 
 func openDB(cfg config) (*sql.DB, error) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
 	"expvar"
@@ -14,10 +15,25 @@ import (
 	"FernArchive/internal/data"
 	"FernArchive/internal/validator"
 
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
 )
 
+func (bknd *backend) requestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get("X-Request-ID")
+		if requestId == "" || len(requestId) > 128 {
+			requestId = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", requestId)
+
+		r = bknd.contextSetRequestId(r, requestId)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (bknd *backend) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -30,48 +46,114 @@ func (bknd *backend) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-func (bknd *backend) rateLimiter(next http.Handler) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
-	var (
-		mtx     sync.Mutex
-		clients = make(map[string]*client)
-	)
+type limiterClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// clientLimiter holds one token bucket per client key. Keys are "ip:<addr>"
+// for anonymous traffic and, with per-user limiting, "user:<id>" for
+// authenticated requests.
+type clientLimiter struct {
+	mtx     sync.Mutex
+	clients map[string]*limiterClient
+	rps     float64
+	burst   int
+}
+
+func newClientLimiter(rps float64, burst int) *clientLimiter {
+	cl := &clientLimiter{clients: make(map[string]*limiterClient), rps: rps, burst: burst}
 	go func() {
 		for {
 			time.Sleep(time.Minute)
-			mtx.Lock()
-			for ip, clnt := range clients {
+			cl.mtx.Lock()
+			for key, clnt := range cl.clients {
 				if time.Since(clnt.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
+					delete(cl.clients, key)
 				}
 			}
-			mtx.Unlock()
+			cl.mtx.Unlock()
 		}
 	}()
+	return cl
+}
+
+func (cl *clientLimiter) client(key string) *limiterClient {
+	if _, found := cl.clients[key]; !found {
+		cl.clients[key] = &limiterClient{limiter: rate.NewLimiter(rate.Limit(cl.rps), cl.burst)}
+	}
+	cl.clients[key].lastSeen = time.Now()
+	return cl.clients[key]
+}
+
+// reserve takes a token from the key's bucket. When the bucket is empty
+// nothing is taken and the time until the next token is returned.
+func (cl *clientLimiter) reserve(key string) time.Duration {
+	cl.mtx.Lock()
+	defer cl.mtx.Unlock()
+
+	reservation := cl.client(key).limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay
+	}
+	return 0
+}
+
+// wait returns how long until the key's bucket holds a token, without
+// taking one.
+func (cl *clientLimiter) wait(key string) time.Duration {
+	cl.mtx.Lock()
+	defer cl.mtx.Unlock()
+
+	tokens := cl.client(key).limiter.Tokens()
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / cl.rps * float64(time.Second))
+}
+
+func (bknd *backend) rateLimiter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if bknd.config.limiter.enabled {
-			ip := realip.FromRequest(r)
-			mtx.Lock()
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{limiter: rate.NewLimiter(
-					rate.Limit(bknd.config.limiter.rps), bknd.config.limiter.burst)}
-			}
-			clients[ip].lastSeen = time.Now()
+			key := "ip:" + realip.FromRequest(r)
 
-			if !clients[ip].limiter.Allow() {
-				mtx.Unlock()
-				bknd.rateLimitExceededResponse(w, r)
+			if bknd.config.limiter.perUser {
+				if user := bknd.contextGetUser(r); !user.IsAnonymous() {
+					key = "user:" + user.Id
+				}
+			}
+			if delay := bknd.limiter.reserve(key); delay > 0 {
+				bknd.rateLimitExceededResponse(w, r, delay)
 				return
 			}
-			mtx.Unlock()
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// With per-user limiting the limiter sits behind authenticate, so requests
+// with a bad token would never reach it. Those rejections are instead charged
+// to a per-IP "auth:" bucket, and once that is empty further tokens from the
+// address are refused before they cost a database lookup.
+func (bknd *backend) authAttemptsLimited(w http.ResponseWriter, r *http.Request) bool {
+	if !bknd.config.limiter.enabled || !bknd.config.limiter.perUser {
+		return false
+	}
+	if delay := bknd.limiter.wait("auth:" + realip.FromRequest(r)); delay > 0 {
+		bknd.rateLimitExceededResponse(w, r, delay)
+		return true
+	}
+	return false
+}
+
+func (bknd *backend) rejectAuthToken(w http.ResponseWriter, r *http.Request) {
+	if bknd.config.limiter.enabled && bknd.config.limiter.perUser {
+		bknd.limiter.reserve("auth:" + realip.FromRequest(r))
+	}
+	bknd.invalidAuthTokenResponse(w, r)
+}
+
 func (bknd *backend) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -83,27 +165,45 @@ func (bknd *backend) authenticate(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
+		if bknd.authAttemptsLimited(w, r) {
+			return
+		}
 		headerParts := strings.SplitN(authHeader, " ", 2)
 
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			bknd.invalidAuthTokenResponse(w, r)
+			bknd.rejectAuthToken(w, r)
 			return
 		}
 		authToken := headerParts[1]
+
+		if strings.Count(authToken, ".") == 2 {
+			if bknd.config.jwt.secret == "" {
+				bknd.rejectAuthToken(w, r)
+				return
+			}
+			user, err := data.ParseJWT(authToken, data.ScopeAuthentication, []byte(bknd.config.jwt.secret))
+			if err != nil {
+				bknd.rejectAuthToken(w, r)
+				return
+			}
+			r = bknd.contextSetUser(r, user)
+			next.ServeHTTP(w, r)
+			return
+		}
 		vldtr := validator.NewValidator()
 
 		if data.ValidateTokenPlainText(vldtr, authToken); !vldtr.Valid() {
-			bknd.invalidAuthTokenResponse(w, r)
+			bknd.rejectAuthToken(w, r)
 			return
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 		defer cancel()
 
 		user, err := bknd.models.Users.GetForToken(ctx, data.ScopeAuthentication, authToken)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
-				bknd.invalidAuthTokenResponse(w, r)
+				bknd.rejectAuthToken(w, r)
 			default:
 				bknd.serverErrorResponse(w, r, err)
 			}
@@ -118,7 +218,7 @@ func (bknd *backend) requirePermission(code string, next http.HandlerFunc) http.
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		user := bknd.contextGetUser(r)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 		defer cancel()
 
 		permissions, err := bknd.models.Permissions.GetAllForUser(ctx, user.Id)
@@ -192,6 +292,193 @@ func (bknd *backend) enableCORS(next http.Handler) http.Handler {
 	})
 }
 
+type headResponseWriter struct {
+	wrapped    http.ResponseWriter
+	statusCode int
+	length     int
+}
+
+func (hrw *headResponseWriter) Header() http.Header {
+	return hrw.wrapped.Header()
+}
+
+func (hrw *headResponseWriter) WriteHeader(statusCode int) {
+	if hrw.statusCode == 0 {
+		hrw.statusCode = statusCode
+	}
+}
+
+func (hrw *headResponseWriter) Write(byt []byte) (int, error) {
+	if hrw.statusCode == 0 {
+		hrw.statusCode = http.StatusOK
+	}
+	hrw.length += len(byt)
+	return len(byt), nil
+}
+
+func (hrw *headResponseWriter) Unwrap() http.ResponseWriter {
+	return hrw.wrapped
+}
+
+func (bknd *backend) headOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hrw := &headResponseWriter{wrapped: w}
+		next.ServeHTTP(hrw, r)
+
+		if hrw.statusCode == 0 {
+			hrw.statusCode = http.StatusOK
+		}
+		if hrw.statusCode != http.StatusNotModified && hrw.statusCode != http.StatusNoContent {
+			w.Header().Set("Content-Length", strconv.Itoa(hrw.length))
+		}
+		w.WriteHeader(hrw.statusCode)
+	}
+}
+
+const gzipMinSize = 1024
+
+type gzipResponseWriter struct {
+	wrapped    http.ResponseWriter
+	gzw        *gzip.Writer
+	buf        []byte
+	statusCode int
+	decided    bool
+	compress   bool
+}
+
+func newGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	return &gzipResponseWriter{
+		wrapped:    w,
+		statusCode: http.StatusOK,
+	}
+}
+
+func (grw *gzipResponseWriter) Header() http.Header {
+	return grw.wrapped.Header()
+}
+
+func (grw *gzipResponseWriter) WriteHeader(statusCode int) {
+	if !grw.decided {
+		grw.statusCode = statusCode
+	}
+}
+
+func (grw *gzipResponseWriter) Write(byt []byte) (int, error) {
+	if !grw.decided {
+		grw.buf = append(grw.buf, byt...)
+		if len(grw.buf) < gzipMinSize {
+			return len(byt), nil
+		}
+		if err := grw.decide(); err != nil {
+			return 0, err
+		}
+		return len(byt), nil
+	}
+	if grw.compress {
+		return grw.gzw.Write(byt)
+	}
+	return grw.wrapped.Write(byt)
+}
+
+func (grw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return grw.wrapped
+}
+
+func (grw *gzipResponseWriter) decide() error {
+	grw.decided = true
+
+	header := grw.wrapped.Header()
+	if header.Get("Content-Type") == "" && len(grw.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(grw.buf))
+	}
+	grw.compress = len(grw.buf) >= gzipMinSize &&
+		header.Get("Content-Encoding") == "" &&
+		grw.statusCode != http.StatusNoContent &&
+		grw.statusCode != http.StatusNotModified &&
+		compressibleContentType(header.Get("Content-Type"))
+
+	if grw.compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		grw.gzw = gzip.NewWriter(grw.wrapped)
+	}
+	grw.wrapped.WriteHeader(grw.statusCode)
+
+	buf := grw.buf
+	grw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if grw.compress {
+		_, err = grw.gzw.Write(buf)
+	} else {
+		_, err = grw.wrapped.Write(buf)
+	}
+	return err
+}
+
+func (grw *gzipResponseWriter) Close() error {
+	if !grw.decided {
+		if err := grw.decide(); err != nil {
+			return err
+		}
+	}
+	if grw.compress {
+		return grw.gzw.Close()
+	}
+	return nil
+}
+
+func compressibleContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/javascript",
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+json"):
+		return true
+	default:
+		return false
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		qvalue, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(qvalue, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+func (bknd *backend) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		grw := newGzipResponseWriter(w)
+		defer func() {
+			if err := grw.Close(); err != nil {
+				bknd.logError(r, err)
+			}
+		}()
+		next.ServeHTTP(grw, r)
+	})
+}
+
 type metricsResponseWriter struct {
 	wrapped       http.ResponseWriter
 	statusCode    int
@@ -234,17 +521,42 @@ func (bknd *backend) requestMetrics(next http.Handler) http.Handler {
 		responsesSent              = expvar.NewInt("responses_sent")
 		responsesSentByStatus      = expvar.NewMap("responses_sent_by_status")
 	)
+	var (
+		promRequestsReceived = prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_requests_received_total",
+			Help: "Total number of HTTP requests received.",
+		})
+		promResponsesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_responses_sent_total",
+			Help: "Total number of HTTP responses sent, by method and status code.",
+		}, []string{"method", "status"})
+		promProcessingTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to process HTTP requests, by method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "status"})
+	)
+	prometheus.MustRegister(promRequestsReceived, promResponsesSent, promProcessingTime)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		requestsReceived.Add(1)
+		promRequestsReceived.Inc()
 
 		mrw := newMetricsResponseWriter(w)
 		next.ServeHTTP(mrw, r)
 
+		status := strconv.Itoa(mrw.statusCode)
 		responsesSent.Add(1)
-		responsesSentByStatus.Add(strconv.Itoa(mrw.statusCode), 1)
+		responsesSentByStatus.Add(status, 1)
+		promResponsesSent.WithLabelValues(r.Method, status).Inc()
+
+		duration := time.Since(start)
+		processingTimeMicroseconds.Add(duration.Microseconds())
+		promProcessingTime.WithLabelValues(r.Method, status).Observe(duration.Seconds())
 
-		duration := time.Since(start).Microseconds()
-		processingTimeMicroseconds.Add(duration)
+		bknd.logger.Debug("request completed", "request_id", bknd.contextGetRequestId(r),
+			"method", r.Method, "uri", r.URL.RequestURI(), "status", mrw.statusCode, "duration", duration,
+		)
 	})
 }
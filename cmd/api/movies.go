@@ -5,12 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
 
 	"FernArchive/internal/data"
 	"FernArchive/internal/validator"
 )
 
+const maxBatchSize = 100
+
+type paginationLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
 func (bknd *backend) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Title   string       `json:"title"`
@@ -34,7 +43,7 @@ func (bknd *backend) createMovieHandler(w http.ResponseWriter, r *http.Request)
 		bknd.failedValidationResponse(w, r, vldtr.Errors)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
 	err = bknd.models.Movies.Insert(ctx, movie)
@@ -51,13 +60,79 @@ func (bknd *backend) createMovieHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (bknd *backend) createMoviesBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var input []struct {
+		Title   string       `json:"title"`
+		Runtime data.Runtime `json:"runtime"`
+		Year    int32        `json:"year"`
+		Genres  []string     `json:"genres"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	vldtr.Check(len(input) >= 1, "movies", "must contain at least 1 movie")
+	vldtr.Check(len(input) <= maxBatchSize, "movies", fmt.Sprintf("must not contain more than %d movies", maxBatchSize))
+
+	if !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	var (
+		movies = make([]*data.Movie, 0, len(input))
+		errs   = make(map[string]map[string]string)
+	)
+	for idx, in := range input {
+		movie := &data.Movie{
+			Title:   in.Title,
+			Runtime: in.Runtime,
+			Year:    in.Year,
+			Genres:  in.Genres,
+		}
+		vldtr := validator.NewValidator()
+
+		if data.ValidateMovie(vldtr, movie); !vldtr.Valid() {
+			errs[strconv.Itoa(idx)] = vldtr.Errors
+		}
+		movies = append(movies, movie)
+	}
+	if len(errs) > 0 {
+		bknd.errorResponseJSON(w, r, http.StatusUnprocessableEntity, errs)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	err = bknd.models.Movies.InsertMany(ctx, movies)
+	if err != nil {
+		var itemErr *data.BatchItemError
+		switch {
+		case errors.Is(err, data.ErrGenresMismatch) && errors.As(err, &itemErr):
+			errs[strconv.Itoa(itemErr.Index)] = map[string]string{"genres": "must only contain existing genres"}
+			bknd.errorResponseJSON(w, r, http.StatusUnprocessableEntity, errs)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusCreated, envelope{"movies": movies}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+// showMovieHandler responds with a weak ETag built from the id, version and rating
+// aggregates (see weakETag). A matching If-None-Match yields 304 Not Modified.
 func (bknd *backend) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := bknd.readIdParam(r)
 	if err != nil {
 		bknd.notFoundResponse(w, r)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
 	movie, err := bknd.models.Movies.Get(ctx, id)
@@ -70,7 +145,17 @@ func (bknd *backend) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	err = bknd.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	etag := bknd.weakETag(movie)
+
+	if bknd.etagMatches(r, etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
 	if err != nil {
 		bknd.serverErrorResponse(w, r, err)
 	}
@@ -91,22 +176,60 @@ func (bknd *backend) listMovieHandler(w http.ResponseWriter, r *http.Request) {
 	input.Filters.PageSize = bknd.readInt(qs, "page_size", 20, vldtr)
 	input.Filters.Page = bknd.readInt(qs, "page", 1, vldtr)
 
+	input.Filters.YearFrom = bknd.readInt(qs, "year_from", 0, vldtr)
+	input.Filters.YearTo = bknd.readInt(qs, "year_to", 0, vldtr)
+
 	input.Filters.Sort = bknd.readString(qs, "sort", "id")
-	input.Filters.SortParams = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortParams = []string{"id", "title", "year", "runtime", "created_at",
+		"-id", "-title", "-year", "-runtime", "-created_at",
+	}
+	input.Filters.Cursor = bknd.readString(qs, "cursor", "")
+
+	vldtr.Check(validator.Unique(input.Genres), "genres", "must not contain duplicate values")
+
+	input.Filters.GenreMatch = bknd.readString(qs, "genre_match", data.GenreMatchAll)
+	if len(input.Genres) > 0 {
+		vldtr.Check(validator.PermittedValue(input.Filters.GenreMatch, data.GenreMatchAll, data.GenreMatchAny),
+			"genre_match", "must be either all or any")
+	}
 
 	if data.ValidateFilters(vldtr, input.Filters); !vldtr.Valid() {
 		bknd.failedValidationResponse(w, r, vldtr.Errors)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
-	movies, metadata, err := bknd.models.Movies.GetAllByTitle(ctx, input.Title, input.Filters)
+	movies, metadata, err := bknd.models.Movies.GetAllByTitle(ctx, input.Title, input.Genres, input.Filters)
 	if err != nil {
 		bknd.serverErrorResponse(w, r, err)
 		return
 	}
-	err = bknd.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		err = bknd.writeMoviesCSV(w, movies)
+		if err != nil {
+			bknd.logError(r, err)
+		}
+		return
+	}
+	links := paginationLinks{Self: bknd.absoluteURL(r, "", "")}
+
+	switch {
+	case input.Filters.Cursor != "":
+		if metadata.NextCursor != "" {
+			links.Next = bknd.absoluteURL(r, "cursor", metadata.NextCursor)
+		}
+	case metadata.TotalRecords > 0:
+		if metadata.CurrentPage < metadata.LastPage {
+			links.Next = bknd.absoluteURL(r, "page", strconv.Itoa(metadata.CurrentPage+1))
+		}
+		if metadata.CurrentPage > metadata.FirstPage {
+			links.Prev = bknd.absoluteURL(r, "page", strconv.Itoa(metadata.CurrentPage-1))
+		}
+	}
+	env := envelope{"movies": movies, "metadata": metadata, "links": links}
+
+	err = bknd.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		bknd.serverErrorResponse(w, r, err)
 	}
@@ -118,7 +241,7 @@ func (bknd *backend) updateMovieHandler(w http.ResponseWriter, r *http.Request)
 		bknd.notFoundResponse(w, r)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
 	movie, err := bknd.models.Movies.Get(ctx, id)
@@ -171,9 +294,14 @@ func (bknd *backend) deleteMovieHandler(w http.ResponseWriter, r *http.Request)
 		bknd.notFoundResponse(w, r)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
+	posterPath, err := bknd.models.Movies.GetPosterPath(ctx, id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
 	err = bknd.models.Movies.Delete(ctx, id)
 	if err != nil {
 		switch {
@@ -184,8 +312,112 @@ func (bknd *backend) deleteMovieHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	if posterPath != "" {
+		bknd.removePoster(posterPath)
+	}
 	err = bknd.writeJSON(w, http.StatusOK, envelope{"message": "movie deleted successfully"}, nil)
 	if err != nil {
 		bknd.serverErrorResponse(w, r, err)
 	}
 }
+
+func (bknd *backend) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := bknd.readIdParam(r)
+	if err != nil {
+		bknd.notFoundResponse(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	err = bknd.models.Movies.Restore(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	movie, err := bknd.models.Movies.Get(ctx, id)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) rateMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := bknd.readIdParam(r)
+	if err != nil {
+		bknd.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Score int `json:"score"`
+	}
+	err = bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	if data.ValidateRating(vldtr, input.Score); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	_, err = bknd.models.Movies.Get(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	user := bknd.contextGetUser(r)
+
+	err = bknd.models.Ratings.Upsert(ctx, user.Id, id, input.Score)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownUser):
+			bknd.invalidAuthTokenResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	movie, err := bknd.models.Movies.Get(ctx, id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) movieStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	stats, err := bknd.models.Movies.Stats(ctx)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"stats": stats}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"FernArchive/internal/data"
+	"FernArchive/internal/validator"
+)
+
+const maxPosterBytes = 5 << 20
+
+var posterExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+func (bknd *backend) uploadPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := bknd.readIdParam(r)
+	if err != nil {
+		bknd.notFoundResponse(w, r)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPosterBytes)
+
+	file, _, err := r.FormFile("poster")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			bknd.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			bknd.badRequestResponse(w, r, errors.New("body must be multipart/form-data with a poster file"))
+		}
+		return
+	}
+	defer file.Close()
+
+	// sniff the first bytes instead of trusting the client's declared type
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+
+	vldtr := validator.NewValidator()
+	if data.ValidatePoster(vldtr, contentType); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	// the upload is read before the query timeout starts ticking
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	_, err = bknd.models.Movies.Get(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	oldPath, err := bknd.models.Movies.GetPosterPath(ctx, id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	relPath := fmt.Sprintf("%d%s", id, posterExtensions[contentType])
+
+	err = bknd.savePoster(relPath, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	err = bknd.models.Movies.SetPosterPath(ctx, id, relPath)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	if oldPath != "" && oldPath != relPath {
+		bknd.removePoster(oldPath)
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/poster", id))
+
+	err = bknd.writeJSON(w, http.StatusCreated, envelope{"message": "poster uploaded successfully"}, headers)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) showPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := bknd.readIdParam(r)
+	if err != nil {
+		bknd.notFoundResponse(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	relPath, err := bknd.models.Movies.GetPosterPath(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	file, err := os.Open(filepath.Join(bknd.config.posterDir, relPath))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	for contentType, ext := range posterExtensions {
+		if filepath.Ext(relPath) == ext {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
+	http.ServeContent(w, r, relPath, info.ModTime(), file)
+}
+
+// savePoster writes the image to a temporary file first so a failed upload
+// never leaves a truncated poster behind.
+func (bknd *backend) savePoster(relPath string, src io.Reader) error {
+	err := os.MkdirAll(bknd.config.posterDir, 0o755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(bknd.config.posterDir, "upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(bknd.config.posterDir, relPath))
+}
+
+func (bknd *backend) removePoster(relPath string) {
+	bknd.background(func() {
+		err := os.Remove(filepath.Join(bknd.config.posterDir, relPath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			bknd.logger.Error("failed to remove poster", "path", relPath, "error", err)
+		}
+	})
+}
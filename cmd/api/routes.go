@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (bknd *backend) routes() http.Handler {
@@ -21,22 +22,97 @@ func (bknd *backend) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/movies",
 		bknd.requirePermission("movies:read", bknd.listMovieHandler),
 	)
+	router.HandlerFunc(http.MethodHead, "/v1/movies",
+		bknd.requirePermission("movies:read", bknd.headOnly(bknd.listMovieHandler)),
+	)
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id",
 		bknd.requirePermission("movies:write", bknd.updateMovieHandler),
 	)
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id",
 		bknd.requirePermission("movies:read", bknd.showMovieHandler),
 	)
+	router.HandlerFunc(http.MethodHead, "/v1/movies/:id",
+		bknd.requirePermission("movies:read", bknd.headOnly(bknd.showMovieHandler)),
+	)
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id",
 		bknd.requirePermission("movies:write", bknd.deleteMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore",
+		bknd.requirePermission("movies:write", bknd.restoreMovieHandler),
+	)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/poster",
+		bknd.requirePermission("movies:write", bknd.uploadPosterHandler),
+	)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/poster",
+		bknd.requirePermission("movies:read", bknd.showPosterHandler),
+	)
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/rating",
+		bknd.requireActivatedUser(bknd.rateMovieHandler),
+	)
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", bknd.listGenresHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/genres",
+		bknd.requirePermission("genres:write", bknd.createGenreHandler),
+	)
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", bknd.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", bknd.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", bknd.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me",
+		bknd.requireAuthenticatedUser(bknd.deleteUserHandler),
+	)
+	router.HandlerFunc(http.MethodPut, "/v1/users/email/confirm", bknd.confirmEmailChangeHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/email",
+		bknd.requireActivatedUser(bknd.requestEmailChangeHandler),
+	)
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/password",
+		bknd.requireActivatedUser(bknd.changeUserPasswordHandler),
+	)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/permissions",
+		bknd.requireActivatedUser(bknd.listUserPermissionsHandler),
+	)
+
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/watchlist",
+		bknd.requireActivatedUser(bknd.listWatchlistHandler),
+	)
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/watchlist/:id",
+		bknd.requireActivatedUser(bknd.addWatchlistHandler),
+	)
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/watchlist/:id",
+		bknd.requireActivatedUser(bknd.removeWatchlistHandler),
+	)
 
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", bknd.createAuthTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", bknd.createActivationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", bknd.createPasswordResetTokenHandler)
 
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.Handler(http.MethodGet, "/v1/metrics", promhttp.Handler())
+
+	// httprouter can't register static segments alongside the :id wildcard,
+	// so such routes are matched first and everything else falls through.
+	api := http.NewServeMux()
+	api.Handle("/", router)
+
+	api.Handle("POST /v1/movies/batch",
+		bknd.requirePermission("movies:write", bknd.createMoviesBatchHandler),
+	)
+	api.Handle("GET /v1/movies/stats",
+		bknd.requirePermission("movies:read", bknd.movieStatsHandler),
+	)
+
+	// readiness probes bypass the rate limiter and authentication so that
+	// orchestrators can poll the instance freely.
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/readiness", bknd.recoverPanic(http.HandlerFunc(bknd.readinessHandler)))
+	bknd.limiter = newClientLimiter(bknd.config.limiter.rps, bknd.config.limiter.burst)
+
+	var limited http.Handler
+	if bknd.config.limiter.perUser {
+		limited = bknd.authenticate(bknd.rateLimiter(api))
+	} else {
+		limited = bknd.rateLimiter(bknd.authenticate(api))
+	}
+	mux.Handle("/", bknd.requestMetrics(bknd.compress(bknd.recoverPanic(bknd.enableCORS(limited)))))
 
-	return bknd.requestMetrics(
-		bknd.recoverPanic(bknd.enableCORS(bknd.rateLimiter(bknd.authenticate(router)))))
+	return bknd.requestId(mux)
 }
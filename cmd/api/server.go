@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -22,6 +23,11 @@ func (bknd *backend) serve() error {
 		IdleTimeout:       120 * time.Second,
 		WriteTimeout:      10 * time.Second,
 	}
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+
+	bknd.purgeExpiredTokens(cleanupCtx)
+
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -37,12 +43,30 @@ func (bknd *backend) serve() error {
 			shutdownError <- err
 		}
 		bknd.logger.Info("completing background tasks", "addr", srvr.Addr)
+		stopCleanup()
 		bknd.wtgrp.Wait()
 		shutdownError <- nil
 	}()
-	bknd.logger.Info("server started", "addr", srvr.Addr, "env", bknd.config.env)
-
-	err := srvr.ListenAndServe()
+	var err error
+	if bknd.config.tls.certFile != "" && bknd.config.tls.keyFile != "" {
+		srvr.TLSConfig = &tls.Config{
+			MinVersion:       tls.VersionTLS12,
+			CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			},
+		}
+		bknd.logger.Info("server started", "addr", srvr.Addr, "env", bknd.config.env, "mode", "https")
+		err = srvr.ListenAndServeTLS(bknd.config.tls.certFile, bknd.config.tls.keyFile)
+	} else {
+		bknd.logger.Info("server started", "addr", srvr.Addr, "env", bknd.config.env, "mode", "http")
+		err = srvr.ListenAndServe()
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -52,3 +76,34 @@ func (bknd *backend) serve() error {
 	bknd.logger.Info("server stopped", "addr", srvr.Addr, "env", bknd.config.env)
 	return nil
 }
+
+func (bknd *backend) purgeExpiredTokens(ctx context.Context) {
+	interval := bknd.config.tokenCleanupInterval
+	if interval <= 0 {
+		return
+	}
+	bknd.wtgrp.Add(1)
+	go func() {
+		defer bknd.wtgrp.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				queryCtx, cancel := context.WithTimeout(ctx, bknd.config.db.queryTimeout)
+				purged, err := bknd.models.Tokens.DeleteExpired(queryCtx)
+				cancel()
+
+				if err != nil {
+					bknd.logger.Error("failed to purge expired tokens", "err", err)
+					continue
+				}
+				bknd.logger.Info("purged expired tokens", "count", purged)
+			}
+		}
+	}()
+}
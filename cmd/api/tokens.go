@@ -29,7 +29,7 @@ func (bknd *backend) createAuthTokenHandler(w http.ResponseWriter, r *http.Reque
 		bknd.failedValidationResponse(w, r, vld.Errors)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
 	user, err := bknd.models.Users.GetByEmail(ctx, input.Email)
@@ -51,7 +51,12 @@ func (bknd *backend) createAuthTokenHandler(w http.ResponseWriter, r *http.Reque
 		bknd.invalidCredentialsResponse(w, r)
 		return
 	}
-	token, err := bknd.models.Tokens.NewToken(ctx, user.Id, 360*time.Hour, data.ScopeAuthentication)
+	var token *data.Token
+	if bknd.config.jwt.secret != "" {
+		token, err = data.NewJWT(user, time.Hour, data.ScopeAuthentication, []byte(bknd.config.jwt.secret))
+	} else {
+		token, err = bknd.models.Tokens.NewToken(ctx, user.Id, 360*time.Hour, data.ScopeAuthentication)
+	}
 	if err != nil {
 		bknd.commonErrors(w, r, err)
 		return
@@ -61,3 +66,116 @@ func (bknd *backend) createAuthTokenHandler(w http.ResponseWriter, r *http.Reque
 		bknd.serverErrorResponse(w, r, err)
 	}
 }
+
+func (bknd *backend) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	if data.ValidateEmail(vldtr, input.Email); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	env := envelope{"message": "if an account with that email exists, password reset instructions will be sent to it"}
+
+	user, err := bknd.models.Users.GetByEmail(ctx, input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			if err = bknd.writeJSON(w, http.StatusAccepted, env, nil); err != nil {
+				bknd.serverErrorResponse(w, r, err)
+			}
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	if user.Activated {
+		token, err := bknd.models.Tokens.NewToken(ctx, user.Id, 45*time.Minute, data.ScopePasswordReset)
+		if err != nil {
+			bknd.commonErrors(w, r, err)
+			return
+		}
+		bknd.background(func() {
+			userData := map[string]any{"passwordResetToken": token.PlainText}
+
+			err := bknd.mailer.SendEmail(user.Email, "token_password_reset.gohtml", userData)
+			if err != nil {
+				bknd.logger.Error(err.Error())
+			}
+		})
+	}
+	err = bknd.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) createActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	if data.ValidateEmail(vldtr, input.Email); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	env := envelope{"message": "if an inactive account with that email exists, activation instructions will be sent to it"}
+
+	user, err := bknd.models.Users.GetByEmail(ctx, input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			if err = bknd.writeJSON(w, http.StatusAccepted, env, nil); err != nil {
+				bknd.serverErrorResponse(w, r, err)
+			}
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	if !user.Activated {
+		err = bknd.models.Tokens.DeleteAllForUser(ctx, data.ScopeActivation, user.Id)
+		if err != nil {
+			bknd.commonErrors(w, r, err)
+			return
+		}
+		token, err := bknd.models.Tokens.NewToken(ctx, user.Id, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			bknd.commonErrors(w, r, err)
+			return
+		}
+		bknd.background(func() {
+			userData := map[string]any{"activationToken": token.PlainText,
+				"userId":   user.Id,
+				"username": user.Name,
+			}
+			err := bknd.mailer.SendEmail(user.Email, "user_welcome.gohtml", userData)
+			if err != nil {
+				bknd.logger.Error(err.Error())
+			}
+		})
+	}
+	err = bknd.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
@@ -38,7 +38,7 @@ func (bknd *backend) registerUserHandler(w http.ResponseWriter, r *http.Request)
 		bknd.failedValidationResponse(w, r, vldtr.Errors)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
 	err = bknd.models.Users.InsertUser(ctx, user)
@@ -94,7 +94,7 @@ func (bknd *backend) activateUserHandler(w http.ResponseWriter, r *http.Request)
 		bknd.failedValidationResponse(w, r, vldtr.Errors)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
 	defer cancel()
 
 	user, err := bknd.models.Users.GetForToken(ctx, data.ScopeActivation, input.TokenPlainText)
@@ -130,3 +130,332 @@ func (bknd *backend) activateUserHandler(w http.ResponseWriter, r *http.Request)
 		bknd.serverErrorResponse(w, r, err)
 	}
 }
+
+func (bknd *backend) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlainText string `json:"token"`
+		Password       string `json:"password"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	data.ValidatePasswordPlainTxt(vldtr, input.Password)
+	data.ValidateTokenPlainText(vldtr, input.TokenPlainText)
+
+	if !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	user, err := bknd.models.Users.GetForToken(ctx, data.ScopePasswordReset, input.TokenPlainText)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			vldtr.AddError("token", "invalid or expired password reset token")
+			bknd.failedValidationResponse(w, r, vldtr.Errors)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = user.Password.SetPass(input.Password)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	err = bknd.models.Users.UpdateUser(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			bknd.editConflictResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.models.Tokens.DeleteAllForUser(ctx, data.ScopePasswordReset, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.models.Tokens.DeleteAllForUser(ctx, data.ScopeAuthentication, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.models.Users.RevokeJWTs(ctx, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) changeUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	user, err := bknd.currentUser(ctx, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.invalidAuthTokenResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	correct, err := user.Password.CheckPass(input.CurrentPassword)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	if !correct {
+		bknd.invalidCredentialsResponse(w, r)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	if data.ValidatePasswordPlainTxt(vldtr, input.NewPassword); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	err = user.Password.SetPass(input.NewPassword)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	err = bknd.models.Users.UpdateUser(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			bknd.editConflictResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.models.Tokens.DeleteAllForUser(ctx, data.ScopeAuthentication, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.models.Users.RevokeJWTs(ctx, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully changed"}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) listUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := bknd.contextGetUser(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	permissions, err := bknd.models.Permissions.GetAllForUser(ctx, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"permissions": permissions}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	user, err := bknd.currentUser(ctx, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.invalidAuthTokenResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	correct, err := user.Password.CheckPass(input.Password)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	if !correct {
+		bknd.invalidCredentialsResponse(w, r)
+		return
+	}
+	err = bknd.models.Users.DeleteUser(ctx, user.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"message": "your account was successfully deleted"}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) requestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	user, err := bknd.currentUser(ctx, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.invalidAuthTokenResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	correct, err := user.Password.CheckPass(input.Password)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+		return
+	}
+	if !correct {
+		bknd.invalidCredentialsResponse(w, r)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	data.ValidateEmail(vldtr, input.Email)
+	vldtr.Check(input.Email != user.Email, "email", "must be different from the current email")
+
+	if !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	err = bknd.models.Tokens.DeleteAllForUser(ctx, data.ScopeEmailChange, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	token, err := bknd.models.Tokens.NewEmailChangeToken(ctx, user.Id, 24*time.Hour, input.Email)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	bknd.background(func() {
+		userData := map[string]any{"emailChangeToken": token.PlainText,
+			"username": user.Name,
+		}
+		err := bknd.mailer.SendEmail(input.Email, "token_email_change.gohtml", userData)
+		if err != nil {
+			bknd.logger.Error(err.Error())
+		}
+	})
+	env := envelope{"message": "a confirmation link has been sent to the new email address"}
+
+	err = bknd.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlainText string `json:"token"`
+	}
+	err := bknd.readJSON(w, r, &input)
+	if err != nil {
+		bknd.badRequestResponse(w, r, err)
+		return
+	}
+	vldtr := validator.NewValidator()
+
+	if data.ValidateTokenPlainText(vldtr, input.TokenPlainText); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	user, err := bknd.models.Users.GetForToken(ctx, data.ScopeEmailChange, input.TokenPlainText)
+	if err == nil {
+		user.Email, err = bknd.models.Tokens.GetPendingEmail(ctx, data.ScopeEmailChange, input.TokenPlainText)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			vldtr.AddError("token", "invalid or expired email change token")
+			bknd.failedValidationResponse(w, r, vldtr.Errors)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.models.Users.UpdateUser(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			vldtr.AddError("email", "email already taken")
+			bknd.failedValidationResponse(w, r, vldtr.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			bknd.editConflictResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.models.Tokens.DeleteAllForUser(ctx, data.ScopeEmailChange, user.Id)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
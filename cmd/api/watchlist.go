@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"FernArchive/internal/data"
+	"FernArchive/internal/validator"
+)
+
+func (bknd *backend) addWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := bknd.readIdParam(r)
+	if err != nil {
+		bknd.notFoundResponse(w, r)
+		return
+	}
+	user := bknd.contextGetUser(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	err = bknd.models.Watchlist.Add(ctx, user.Id, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrUnknownUser):
+			bknd.invalidAuthTokenResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"message": "movie added to watchlist"}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) removeWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := bknd.readIdParam(r)
+	if err != nil {
+		bknd.notFoundResponse(w, r)
+		return
+	}
+	user := bknd.contextGetUser(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	err = bknd.models.Watchlist.Remove(ctx, user.Id, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			bknd.notFoundResponse(w, r)
+		default:
+			bknd.commonErrors(w, r, err)
+		}
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"message": "movie removed from watchlist"}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
+
+func (bknd *backend) listWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+	vldtr := validator.NewValidator()
+	qs := r.URL.Query()
+
+	input.Filters.PageSize = bknd.readInt(qs, "page_size", 20, vldtr)
+	input.Filters.Page = bknd.readInt(qs, "page", 1, vldtr)
+
+	input.Filters.Sort = bknd.readString(qs, "sort", "id")
+	input.Filters.SortParams = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+
+	if data.ValidateFilters(vldtr, input.Filters); !vldtr.Valid() {
+		bknd.failedValidationResponse(w, r, vldtr.Errors)
+		return
+	}
+	user := bknd.contextGetUser(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), bknd.config.db.queryTimeout)
+	defer cancel()
+
+	movies, metadata, err := bknd.models.Watchlist.GetAllForUser(ctx, user.Id, input.Filters)
+	if err != nil {
+		bknd.commonErrors(w, r, err)
+		return
+	}
+	err = bknd.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		bknd.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,34 +1,60 @@
 package data
 
 import (
+	"encoding/base64"
+	"strconv"
 	"strings"
+	"time"
 
 	"FernArchive/internal/validator"
 )
 
+const (
+	GenreMatchAll = "all"
+	GenreMatchAny = "any"
+)
+
 type Filters struct {
 	Page       int
 	PageSize   int
 	Sort       string
 	SortParams []string
+	YearFrom   int
+	YearTo     int
+	GenreMatch string
+	Cursor     string
+}
+
+func (fltr *Filters) sortTokens() []string {
+	return strings.Split(fltr.Sort, ",")
 }
 
-func (fltr *Filters) sortParam() string {
+func (fltr *Filters) sortParam(token string) string {
 	for _, param := range fltr.SortParams {
-		if fltr.Sort == param {
-			return strings.TrimPrefix(fltr.Sort, "-")
+		if token == param {
+			return strings.TrimPrefix(token, "-")
 		}
 	}
-	panic("unsafe sort parameter: " + fltr.Sort)
+	panic("unsafe sort parameter: " + token)
 }
 
-func (fltr *Filters) sortOrder() string {
-	if strings.HasPrefix(fltr.Sort, "-") {
+func (fltr *Filters) sortOrder(token string) string {
+	if strings.HasPrefix(token, "-") {
 		return "DESC"
 	}
 	return "ASC"
 }
 
+// orderBy builds the ORDER BY list from the comma-separated sort value, with every
+// column whitelisted against SortParams and id as the final tie-breaker.
+func (fltr *Filters) orderBy() string {
+	var columns []string
+	for _, token := range fltr.sortTokens() {
+		columns = append(columns, fltr.sortParam(token)+" "+fltr.sortOrder(token))
+	}
+	return strings.Join(append(columns, "id ASC"), ", ")
+}
+
 func (fltr *Filters) limit() int {
 	return fltr.PageSize
 }
@@ -37,6 +63,41 @@ func (fltr *Filters) offset() int {
 	return (fltr.Page - 1) * fltr.PageSize
 }
 
+func (fltr *Filters) yearFrom() int {
+	if fltr.YearFrom == 0 {
+		return 1888
+	}
+	return fltr.YearFrom
+}
+
+func (fltr *Filters) yearTo() int {
+	if fltr.YearTo == 0 {
+		return time.Now().Year()
+	}
+	return fltr.YearTo
+}
+
+// afterId decodes the cursor into the last-seen movie id. Cursor mode always
+// orders by id, so the sort parameter is ignored whenever this reports true.
+func (fltr *Filters) afterId() (int64, bool) {
+	if fltr.Cursor == "" {
+		return 0, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(fltr.Cursor)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
 func ValidateFilters(vldtr *validator.Validator, fltr Filters) {
 	vldtr.Check(fltr.Page > 0, "page", "must be greater than zero")
 	vldtr.Check(fltr.Page <= 10_000_000, "page", "must be a maximum of 10 million")
@@ -44,15 +105,36 @@ func ValidateFilters(vldtr *validator.Validator, fltr Filters) {
 	vldtr.Check(fltr.PageSize > 0, "page_size", "must be greater than zero")
 	vldtr.Check(fltr.PageSize <= 100, "page_size", "must be a maximum of 100")
 
-	vldtr.Check(validator.PermittedValue(fltr.Sort, fltr.SortParams...), "sort", "invalid sort value")
+	sortColumns := make([]string, 0, len(fltr.sortTokens()))
+	for _, token := range fltr.sortTokens() {
+		vldtr.Check(validator.PermittedValue(token, fltr.SortParams...), "sort", "invalid sort value")
+		sortColumns = append(sortColumns, strings.TrimPrefix(token, "-"))
+	}
+	vldtr.Check(validator.Unique(sortColumns), "sort", "must not repeat a sort column")
+
+	if fltr.YearFrom != 0 {
+		vldtr.Check(fltr.YearFrom >= 1888, "year_from", "must be greater than 1888")
+		vldtr.Check(fltr.YearFrom <= time.Now().Year(), "year_from", "must not be in the future")
+	}
+	if fltr.YearTo != 0 {
+		vldtr.Check(fltr.YearTo >= 1888, "year_to", "must be greater than 1888")
+		vldtr.Check(fltr.YearTo <= time.Now().Year(), "year_to", "must not be in the future")
+	}
+	vldtr.Check(fltr.yearFrom() <= fltr.yearTo(), "year_from", "must not be greater than year_to")
+
+	if fltr.Cursor != "" {
+		_, ok := fltr.afterId()
+		vldtr.Check(ok, "cursor", "invalid cursor value")
+	}
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -67,3 +149,14 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 		TotalRecords: totalRecords,
 	}
 }
+
+func calculateCursorMetadata(movies []*Movie, remainingRecords, pageSize int) Metadata {
+	if len(movies) == 0 {
+		return Metadata{}
+	}
+	metadata := Metadata{PageSize: pageSize}
+	if remainingRecords > len(movies) {
+		metadata.NextCursor = encodeCursor(movies[len(movies)-1].Id)
+	}
+	return metadata
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"FernArchive/internal/validator"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var ErrDuplicateGenre = errors.New("duplicate genre")
+
+func ValidateGenre(vldtr *validator.Validator, name string) {
+	vldtr.Check(name != "", "name", "must be provided")
+	vldtr.Check(len(name) <= 50, "name", "must not be more than 50 bytes long")
+}
+
+type GenreModel struct {
+	Db *sql.DB
+}
+
+func (mdl *GenreModel) GetAll(ctx context.Context) ([]string, error) {
+	rows, err := mdl.Db.QueryContext(ctx, `SELECT name FROM genres ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Error("Failed to close rows: ", "err", err)
+		}
+	}(rows)
+	genres := []string{}
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		genres = append(genres, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+func (mdl *GenreModel) Insert(ctx context.Context, name string) error {
+	_, err := mdl.Db.ExecContext(ctx, `INSERT INTO genres (name) VALUES (?)`, name)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		switch {
+		case errors.As(err, &mysqlErr) && mysqlErr.Number == 1062:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const jwtIssuer = "FernArchive"
+
+var ErrInvalidJWT = errors.New("invalid or expired jwt")
+
+type JWTClaims struct {
+	Scope        string `json:"scope"`
+	Activated    bool   `json:"activated"`
+	TokenVersion int32  `json:"tv"`
+	jwt.RegisteredClaims
+}
+
+func NewJWT(user *User, ttl time.Duration, scope string, secret []byte) (*Token, error) {
+	now := time.Now()
+	claims := JWTClaims{
+		Scope:        scope,
+		Activated:    user.Activated,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Id,
+			Issuer:    jwtIssuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{
+		PlainText: signed,
+		UserId:    user.Id,
+		Expiry:    claims.ExpiresAt.Time,
+		Scope:     scope,
+	}, nil
+}
+
+// ParseJWT verifies a JWT locally and returns the user its claims describe.
+// Only the id, activation status and token version are set; handlers that
+// need the full record load it with UserModel.GetForTokenVersion.
+func ParseJWT(tokenStr, scope string, secret []byte) (*User, error) {
+	var claims JWTClaims
+
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(*jwt.Token) (any, error) { return secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(jwtIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Scope != scope || claims.Subject == "" {
+		return nil, ErrInvalidJWT
+	}
+	return &User{Id: claims.Subject, Activated: claims.Activated, TokenVersion: claims.TokenVersion}, nil
+}
@@ -12,16 +12,22 @@ var (
 
 type Models struct {
 	Movies      MovieModel
+	Genres      GenreModel
 	Tokens      TokenModel
 	Permissions PermissionModel
+	Ratings     RatingModel
 	Users       UserModel
+	Watchlist   WatchlistModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
 		Movies:      MovieModel{DB: db},
+		Genres:      GenreModel{Db: db},
 		Tokens:      TokenModel{Db: db},
 		Permissions: PermissionModel{Db: db},
+		Ratings:     RatingModel{Db: db},
 		Users:       UserModel{Db: db},
+		Watchlist:   WatchlistModel{Db: db},
 	}
 }
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestModelSignatures pins every model method to a context-first signature.
+// The assignments are checked by the compiler, so a method that drifts from
+// this shape breaks the build of this package's tests.
+func TestModelSignatures(t *testing.T) {
+	m := NewModels(nil)
+
+	// MovieModel
+	var _ func(context.Context, *Movie) error = m.Movies.Insert
+	var _ func(context.Context, []*Movie) error = m.Movies.InsertMany
+	var _ func(context.Context, int64) (*Movie, error) = m.Movies.Get
+	var _ func(context.Context, string, []string, Filters) ([]*Movie, Metadata, error) = m.Movies.GetAllByTitle
+	var _ func(context.Context, *Movie) error = m.Movies.Update
+	var _ func(context.Context, int64) error = m.Movies.Delete
+	var _ func(context.Context, int64) (string, error) = m.Movies.GetPosterPath
+	var _ func(context.Context, int64, string) error = m.Movies.SetPosterPath
+	var _ func(context.Context, int64) error = m.Movies.Restore
+	var _ func(context.Context) (*MovieStats, error) = m.Movies.Stats
+
+	// UserModel
+	var _ func(context.Context, *User) error = m.Users.InsertUser
+	var _ func(context.Context, string) (*User, error) = m.Users.GetByEmail
+	var _ func(context.Context, string, int32) (*User, error) = m.Users.GetForTokenVersion
+	var _ func(context.Context, string) error = m.Users.RevokeJWTs
+	var _ func(context.Context, *User) error = m.Users.UpdateUser
+	var _ func(context.Context, string) error = m.Users.DeleteUser
+	var _ func(context.Context, string, string) (*User, error) = m.Users.GetForToken
+
+	// TokenModel
+	var _ func(context.Context, string, time.Duration, string) (*Token, error) = m.Tokens.NewToken
+	var _ func(context.Context, string, time.Duration, string) (*Token, error) = m.Tokens.NewEmailChangeToken
+	var _ func(context.Context, *Token) error = m.Tokens.Insert
+	var _ func(context.Context, string, string) error = m.Tokens.DeleteAllForUser
+	var _ func(context.Context) (int64, error) = m.Tokens.DeleteExpired
+	var _ func(context.Context, string, string) (string, error) = m.Tokens.GetPendingEmail
+
+	// PermissionModel
+	var _ func(context.Context, string) (Permissions, error) = m.Permissions.GetAllForUser
+	var _ func(context.Context, string, ...string) error = m.Permissions.AddForUser
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -14,11 +15,41 @@ import (
 
 type Movie struct {
 	Id        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 	Title     string    `json:"title"`
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Year      int32     `json:"year,omitempty"`
 	Genres    []string  `json:"genres,omitempty"`
+	Version   int32     `json:"version"`
+
+	AverageRating float64 `json:"average_rating,omitempty"`
+	RatingCount   int64   `json:"rating_count,omitempty"`
+}
+
+type MovieStats struct {
+	TotalMovies    int64            `json:"total_movies"`
+	AverageRuntime float64          `json:"average_runtime"`
+	EarliestYear   int32            `json:"earliest_year,omitempty"`
+	LatestYear     int32            `json:"latest_year,omitempty"`
+	GenreCounts    map[string]int64 `json:"genre_counts"`
+}
+
+// ErrGenresMismatch is returned when a movie names a genre that does not exist.
+var ErrGenresMismatch = errors.New("genres mismatch")
+
+// BatchItemError reports which movie of an InsertMany batch failed.
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("InsertMany failed at index %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
 }
 
 type MovieModel struct {
@@ -26,9 +57,6 @@ type MovieModel struct {
 }
 
 func (mdl *MovieModel) Insert(ctx context.Context, movie *Movie) error {
-	if len(movie.Genres) < 1 {
-		return errors.New("genres must not be empty")
-	}
 	errFn := func(err error) error {
 		return fmt.Errorf("InsertMovie failed: %v", err)
 	}
@@ -38,6 +66,37 @@ func (mdl *MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	if err = insertMovieTx(ctx, tx, movie); err != nil {
+		return errFn(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (mdl *MovieModel) InsertMany(ctx context.Context, movies []*Movie) error {
+	tx, err := mdl.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("InsertMany failed: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for idx, movie := range movies {
+		if err = insertMovieTx(ctx, tx, movie); err != nil {
+			return &BatchItemError{Index: idx, Err: err}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func insertMovieTx(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	if len(movie.Genres) < 1 {
+		return errors.New("genres must not be empty")
+	}
 	placeholders := strings.Repeat("?,", len(movie.Genres))
 	placeholders = placeholders[:len(placeholders)-1]
 	var (
@@ -49,7 +108,7 @@ func (mdl *MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	}
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return errFn(err)
+		return err
 	}
 	defer func(rows *sql.Rows) {
 		if err := rows.Close(); err != nil {
@@ -61,37 +120,41 @@ func (mdl *MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	for rows.Next() {
 		var gid int64
 		if err := rows.Scan(&gid); err != nil {
-			return errFn(err)
+			return err
 		}
 		genreIds = append(genreIds, gid)
 	}
 	if err = rows.Err(); err != nil {
-		return errFn(err)
+		return err
 	}
 	if len(genreIds) != len(movie.Genres) {
-		return errFn(fmt.Errorf("genres mismatch"))
+		return ErrGenresMismatch
 	}
 	res, err := tx.ExecContext(ctx, `INSERT INTO movies (title, year, runtime) VALUES (?, ?, ?)`,
 		movie.Title, movie.Year, movie.Runtime,
 	)
 	if err != nil {
-		return errFn(err)
+		return err
 	}
 	movie.Id, err = res.LastInsertId()
 	if err != nil {
-		return errFn(err)
+		return err
+	}
+	movie.Version = 1
+
+	err = tx.QueryRowContext(ctx, `SELECT created_at, updated_at FROM movies WHERE id = ?`, movie.Id).
+		Scan(&movie.CreatedAt, &movie.UpdatedAt)
+	if err != nil {
+		return err
 	}
 	for _, gid := range genreIds {
 		_, err := tx.ExecContext(ctx, `INSERT INTO movie_genres (movie_id, genre_id) VALUES (?, ?)`,
 			movie.Id, gid,
 		)
 		if err != nil {
-			return errFn(err)
+			return err
 		}
 	}
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
 	return nil
 }
 
@@ -102,17 +165,21 @@ func (mdl *MovieModel) Get(ctx context.Context, movieId int64) (*Movie, error) {
 	errFn := func(err error) error {
 		return fmt.Errorf("GetMovie failed: %v", err)
 	}
-	query := `SELECT m.id, m.created_at, m.title, m.year, m.runtime, GROUP_CONCAT(g.name)
+	query := `SELECT m.id, m.created_at, m.updated_at, m.title, m.year, m.runtime, m.version,
+		    (SELECT COALESCE(AVG(r.score), 0) FROM ratings AS r WHERE r.movie_id = m.id),
+		    (SELECT COUNT(*) FROM ratings AS r WHERE r.movie_id = m.id), GROUP_CONCAT(g.name)
 		    FROM movies AS m 
 		    LEFT JOIN movie_genres AS mg ON mg.movie_id = m.id
-                LEFT JOIN genres AS g ON g.id = mg.genre_id WHERE m.id = ? GROUP BY m.id`
+                LEFT JOIN genres AS g ON g.id = mg.genre_id WHERE m.id = ? AND m.deleted_at IS NULL GROUP BY m.id`
 
 	row := mdl.DB.QueryRowContext(ctx, query, movieId)
 
 	var m Movie
 	var genres string
 
-	err := row.Scan(&m.Id, &m.CreatedAt, &m.Title, &m.Year, &m.Runtime, &genres)
+	err := row.Scan(&m.Id, &m.CreatedAt, &m.UpdatedAt, &m.Title, &m.Year, &m.Runtime, &m.Version,
+		&m.AverageRating, &m.RatingCount, &genres,
+	)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -127,16 +194,58 @@ func (mdl *MovieModel) Get(ctx context.Context, movieId int64) (*Movie, error) {
 	return &m, nil
 }
 
-func (mdl *MovieModel) GetAllByTitle(ctx context.Context, title string, ftr Filters) ([]*Movie, Metadata, error) {
+func (mdl *MovieModel) GetAllByTitle(ctx context.Context, title string, genres []string, ftr Filters,
+) ([]*Movie, Metadata, error) {
+	var (
+		conditions string
+		args       = []any{title}
+	)
+	if ftr.YearFrom != 0 || ftr.YearTo != 0 {
+		conditions += ` AND m.year BETWEEN ? AND ?`
+		args = append(args, ftr.yearFrom(), ftr.yearTo())
+	}
+	if len(genres) > 0 {
+		placeholders := strings.Repeat("?,", len(genres))
+		placeholders = placeholders[:len(placeholders)-1]
+
+		var having string
+		if ftr.GenreMatch != GenreMatchAny {
+			having = ` HAVING COUNT(DISTINCT fg.name) = ?`
+		}
+		conditions += fmt.Sprintf(` AND m.id IN (SELECT fmg.movie_id FROM movie_genres AS fmg
+			  INNER JOIN genres AS fg ON fg.id = fmg.genre_id WHERE fg.name IN (%s)
+			  GROUP BY fmg.movie_id%s)`, placeholders, having,
+		)
+		for _, genre := range genres {
+			args = append(args, genre)
+		}
+		if having != "" {
+			args = append(args, len(genres))
+		}
+	}
+	afterId, cursorMode := ftr.afterId()
+	var orderBy, pagination string
+
+	if cursorMode {
+		conditions += ` AND m.id > ?`
+		args = append(args, afterId)
+
+		orderBy, pagination = "m.id ASC", "LIMIT ?"
+		args = append(args, ftr.limit())
+	} else {
+		orderBy, pagination = ftr.orderBy(), "LIMIT ? OFFSET ?"
+		args = append(args, ftr.limit(), ftr.offset())
+	}
 	query := fmt.Sprintf(
-		`SELECT m.id, m.created_at, m.title, m.year, m.runtime, GROUP_CONCAT(DISTINCT g.name) 
+		`SELECT COUNT(*) OVER(), m.id, m.created_at, m.updated_at, m.title, m.year, m.runtime, m.version,
+			  (SELECT COALESCE(AVG(r.score), 0) FROM ratings AS r WHERE r.movie_id = m.id),
+			  (SELECT COUNT(*) FROM ratings AS r WHERE r.movie_id = m.id), GROUP_CONCAT(DISTINCT g.name)
 			  FROM movies AS m 
 			  LEFT JOIN movie_genres AS mg ON mg.movie_id = m.id
             	  LEFT JOIN genres AS g ON g.id = mg.genre_id 
-			  WHERE LOWER(m.title) LIKE LOWER(CONCAT('%', ?, '%'))
-			  GROUP BY m.id ORDER BY %s %s, id ASC LIMIT ? OFFSET ?`, ftr.sortParam(), ftr.sortOrder(),
+			  WHERE m.deleted_at IS NULL AND LOWER(m.title) LIKE LOWER(CONCAT('%%', ?, '%%'))%s
+			  GROUP BY m.id ORDER BY %s %s`, conditions, orderBy, pagination,
 	)
-	args := []any{title, ftr.limit(), ftr.offset()}
 
 	rows, err := mdl.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -148,25 +257,31 @@ func (mdl *MovieModel) GetAllByTitle(ctx context.Context, title string, ftr Filt
 		}
 	}(rows)
 	var (
-		movies   []*Movie
-		genres   string
-		metadata Metadata
+		movies       []*Movie
+		genreList    string
+		metadata     Metadata
+		totalRecords int
 	)
 	for rows.Next() {
 		var m Movie
-		err := rows.Scan(&m.Id, &m.CreatedAt, &m.Title, &m.Year, &m.Runtime, &genres)
+		err := rows.Scan(&totalRecords, &m.Id, &m.CreatedAt, &m.UpdatedAt, &m.Title, &m.Year, &m.Runtime,
+			&m.Version, &m.AverageRating, &m.RatingCount, &genreList,
+		)
 		if err != nil {
 			return nil, metadata, err
 		}
-		if genres != "" {
-			m.Genres = strings.Split(genres, ",")
+		if genreList != "" {
+			m.Genres = strings.Split(genreList, ",")
 		}
 		movies = append(movies, &m)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, metadata, err
 	}
-	metadata = calculateMetadata(len(movies), ftr.Page, ftr.PageSize)
+	if cursorMode {
+		return movies, calculateCursorMetadata(movies, totalRecords, ftr.PageSize), nil
+	}
+	metadata = calculateMetadata(totalRecords, ftr.Page, ftr.PageSize)
 	return movies, metadata, nil
 }
 
@@ -180,8 +295,9 @@ func (mdl *MovieModel) Update(ctx context.Context, movie *Movie) error {
 	}
 	defer func() { _ = tx.Rollback() }()
 	var (
-		query = `UPDATE movies SET title=?, runtime=?, year=? WHERE id = ?`
-		args  = []any{movie.Title, movie.Runtime, movie.Year, movie.Id}
+		query = `UPDATE movies SET title=?, runtime=?, year=?, version = version + 1, updated_at = UTC_TIMESTAMP()
+			   WHERE id = ? AND version = ? AND deleted_at IS NULL`
+		args = []any{movie.Title, movie.Runtime, movie.Year, movie.Id, movie.Version}
 	)
 	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
@@ -194,6 +310,15 @@ func (mdl *MovieModel) Update(ctx context.Context, movie *Movie) error {
 	if row != 1 {
 		return ErrEditConflict
 	}
+	var (
+		version   int32
+		updatedAt time.Time
+	)
+	err = tx.QueryRowContext(ctx, `SELECT version, updated_at FROM movies WHERE id = ?`, movie.Id).
+		Scan(&version, &updatedAt)
+	if err != nil {
+		return errFn(err)
+	}
 	genreMap := make(map[string]int64)
 
 	rows, err := tx.QueryContext(ctx, `SELECT id, name FROM genres`)
@@ -239,6 +364,8 @@ func (mdl *MovieModel) Update(ctx context.Context, movie *Movie) error {
 	if err = tx.Commit(); err != nil {
 		return errFn(fmt.Errorf("failed to commit transaction: %w", err))
 	}
+	movie.Version = version
+	movie.UpdatedAt = updatedAt
 	return nil
 }
 
@@ -246,7 +373,72 @@ func (mdl *MovieModel) Delete(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	result, err := mdl.DB.ExecContext(ctx, `DELETE FROM movies WHERE id = ?`, id)
+	tx, err := mdl.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// the poster file is removed by the caller, so its path goes too
+	query := `UPDATE movies SET deleted_at = UTC_TIMESTAMP(), poster_path = NULL
+		    WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrRecordNotFound
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM ratings WHERE movie_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPosterPath returns the stored poster path of a movie, relative to the
+// poster directory. ErrRecordNotFound is returned when the movie does not
+// exist or has no poster.
+func (mdl *MovieModel) GetPosterPath(ctx context.Context, id int64) (string, error) {
+	if id < 1 {
+		return "", ErrRecordNotFound
+	}
+	query := `SELECT poster_path FROM movies WHERE id = ? AND deleted_at IS NULL`
+
+	var path sql.NullString
+	err := mdl.DB.QueryRowContext(ctx, query, id).Scan(&path)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+	if !path.Valid {
+		return "", ErrRecordNotFound
+	}
+	return path.String, nil
+}
+
+func (mdl *MovieModel) SetPosterPath(ctx context.Context, id int64, path string) error {
+	query := `UPDATE movies SET poster_path = ? WHERE id = ? AND deleted_at IS NULL`
+
+	_, err := mdl.DB.ExecContext(ctx, query, path, id)
+	return err
+}
+
+func (mdl *MovieModel) Restore(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `UPDATE movies SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+
+	result, err := mdl.DB.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -260,6 +452,37 @@ func (mdl *MovieModel) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (mdl *MovieModel) Stats(ctx context.Context) (*MovieStats, error) {
+	query := `SELECT COUNT(*), COALESCE(AVG(m.runtime), 0), COALESCE(MIN(m.year), 0), COALESCE(MAX(m.year), 0),
+		    (SELECT JSON_OBJECTAGG(gc.name, gc.total) FROM (
+			 SELECT g.name, COUNT(gm.id) AS total FROM genres AS g
+			 LEFT JOIN movie_genres AS mg ON mg.genre_id = g.id
+			 LEFT JOIN movies AS gm ON gm.id = mg.movie_id AND gm.deleted_at IS NULL
+			 GROUP BY g.id, g.name) AS gc)
+		    FROM movies AS m WHERE m.deleted_at IS NULL`
+
+	var (
+		stats       MovieStats
+		genreCounts []byte
+	)
+	err := mdl.DB.QueryRowContext(ctx, query).Scan(&stats.TotalMovies,
+		&stats.AverageRuntime,
+		&stats.EarliestYear,
+		&stats.LatestYear,
+		&genreCounts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("MovieStats failed: %v", err)
+	}
+	stats.GenreCounts = make(map[string]int64)
+	if len(genreCounts) > 0 {
+		if err = json.Unmarshal(genreCounts, &stats.GenreCounts); err != nil {
+			return nil, fmt.Errorf("MovieStats failed: %v", err)
+		}
+	}
+	return &stats, nil
+}
+
 func (movie *Movie) ApplyPartialUpdates(title *string, year *int32, runtime *Runtime, genres []string) {
 	if title != nil {
 		movie.Title = *title
@@ -275,6 +498,11 @@ func (movie *Movie) ApplyPartialUpdates(title *string, year *int32, runtime *Run
 	}
 }
 
+func ValidatePoster(vldtr *validator.Validator, contentType string) {
+	vldtr.Check(validator.PermittedValue(contentType, "image/jpeg", "image/png"),
+		"poster", "must be a JPEG or PNG image")
+}
+
 func ValidateMovie(vldtr *validator.Validator, movie *Movie) {
 	vldtr.Check(movie.Title != "", "title", "must be provided")
 	vldtr.Check(len(movie.Title) <= 50, "title", "must not be more than 500 bytes long")
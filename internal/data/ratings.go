@@ -0,0 +1,39 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"FernArchive/internal/validator"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func ValidateRating(vldtr *validator.Validator, score int) {
+	vldtr.Check(score >= 1, "score", "must be at least 1")
+	vldtr.Check(score <= 5, "score", "must not be more than 5")
+}
+
+type RatingModel struct {
+	Db *sql.DB
+}
+
+func (mdl *RatingModel) Upsert(ctx context.Context, userId string, movieId int64, score int) error {
+	var (
+		query = `INSERT INTO ratings (user_id, movie_id, score) VALUES (?, ?, ?)
+			   ON DUPLICATE KEY UPDATE score = VALUES(score)`
+		args = []any{userId, movieId, score}
+	)
+	_, err := mdl.Db.ExecContext(ctx, query, args...)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		switch {
+		case errors.As(err, &mysqlErr) && mysqlErr.Number == 1452:
+			return ErrUnknownUser
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -20,6 +21,11 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 
 //goland:noinspection GoMixedReceiverTypes
 func (r *Runtime) UnmarshalJSON(length []byte) error {
+	var mins int32
+	if err := json.Unmarshal(length, &mins); err == nil {
+		*r = Runtime(mins)
+		return nil
+	}
 	unquotedLength, err := strconv.Unquote(string(length))
 	if err != nil {
 		return ErrInvalidRuntimeFormat
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
 
 	"FernArchive/internal/validator"
@@ -14,14 +15,17 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	ScopePasswordReset  = "password-reset"
+	ScopeEmailChange    = "email-change"
 )
 
 type Token struct {
-	PlainText string    `json:"token"`
-	Hash      []byte    `json:"-"`
-	UserId    string    `json:"-"`
-	Expiry    time.Time `json:"expiry"`
-	Scope     string    `json:"-"`
+	PlainText    string    `json:"token"`
+	Hash         []byte    `json:"-"`
+	UserId       string    `json:"-"`
+	Expiry       time.Time `json:"expiry"`
+	Scope        string    `json:"-"`
+	PendingEmail string    `json:"-"`
 }
 
 func generateToken(userId string, ttl time.Duration, scope string) (*Token, error) {
@@ -59,10 +63,26 @@ func (mdl *TokenModel) NewToken(ctx context.Context, userId string, ttl time.Dur
 	return token, err
 }
 
+func (mdl *TokenModel) NewEmailChangeToken(ctx context.Context, userId string, ttl time.Duration, email string,
+) (*Token, error) {
+	token, err := generateToken(userId, ttl, ScopeEmailChange)
+	if err != nil {
+		return nil, err
+	}
+	token.PendingEmail = email
+
+	err = mdl.Insert(ctx, token)
+	return token, err
+}
+
 func (mdl *TokenModel) Insert(ctx context.Context, token *Token) error {
+	var pendingEmail *string
+	if token.PendingEmail != "" {
+		pendingEmail = &token.PendingEmail
+	}
 	var (
-		query = `INSERT INTO tokens (hash, user_id, expiry, scope) VALUES (?, ?, ?, ?)`
-		args  = []any{token.Hash, token.UserId, token.Expiry, token.Scope}
+		query = `INSERT INTO tokens (hash, user_id, expiry, scope, pending_email) VALUES (?, ?, ?, ?, ?)`
+		args  = []any{token.Hash, token.UserId, token.Expiry, token.Scope, pendingEmail}
 	)
 	_, err := mdl.Db.ExecContext(ctx, query, args...)
 	return err
@@ -76,3 +96,34 @@ func (mdl *TokenModel) DeleteAllForUser(ctx context.Context, scope string, userI
 	_, err := mdl.Db.ExecContext(ctx, query, args...)
 	return err
 }
+
+func (mdl *TokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := mdl.Db.ExecContext(ctx, `DELETE FROM tokens WHERE expiry < UTC_TIMESTAMP()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (mdl *TokenModel) GetPendingEmail(ctx context.Context, scope, plainTxt string) (string, error) {
+	var (
+		hash  = sha256.Sum256([]byte(plainTxt))
+		query = `SELECT pending_email FROM tokens WHERE hash = ? AND scope = ? AND expiry > ?`
+		args  = []any{hash[:], scope, time.Now()}
+	)
+	var email sql.NullString
+
+	err := mdl.Db.QueryRowContext(ctx, query, args...).Scan(&email)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+	if !email.Valid {
+		return "", ErrRecordNotFound
+	}
+	return email.String, nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"FernArchive/internal/validator"
@@ -16,8 +17,22 @@ import (
 
 var ErrDuplicateEmail = errors.New("duplicate email")
 
+// ErrUnknownUser is returned when a write references a user that no longer
+// exists, e.g. one deleted while still holding an unexpired JWT.
+var ErrUnknownUser = errors.New("unknown user")
+
 var AnonymousUser = &User{}
 
+var bcryptCost = 12
+
+func SetBcryptCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	bcryptCost = cost
+	return nil
+}
+
 type User struct {
 	Id        string    `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -25,6 +40,8 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
+
+	TokenVersion int32 `json:"-"`
 }
 
 func (usr *User) IsAnonymous() bool {
@@ -37,7 +54,7 @@ type password struct {
 }
 
 func (pass *password) SetPass(plainTxtPass string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plainTxtPass), 12)
+	hash, err := bcrypt.GenerateFromPassword([]byte(plainTxtPass), bcryptCost)
 	if err != nil {
 		return err
 	}
@@ -108,7 +125,8 @@ func (mdl *UserModel) InsertUser(ctx context.Context, user *User) error {
 }
 
 func (mdl *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, created_at, name, email, password_hash, activated FROM users WHERE email = ?`
+	query := `SELECT id, created_at, name, email, password_hash, activated, token_version
+		    FROM users WHERE email = ?`
 	var user User
 
 	err := mdl.Db.QueryRowContext(ctx, query, email).Scan(&user.Id,
@@ -117,6 +135,7 @@ func (mdl *UserModel) GetByEmail(ctx context.Context, email string) (*User, erro
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.TokenVersion,
 	)
 	if err != nil {
 		switch {
@@ -129,6 +148,40 @@ func (mdl *UserModel) GetByEmail(ctx context.Context, email string) (*User, erro
 	return &user, nil
 }
 
+// GetForTokenVersion loads a user, failing with ErrRecordNotFound when the
+// user was deleted or its tokens were revoked since tokenVersion was read.
+func (mdl *UserModel) GetForTokenVersion(ctx context.Context, id string, tokenVersion int32) (*User, error) {
+	query := `SELECT id, created_at, name, email, password_hash, activated, token_version
+		    FROM users WHERE id = ? AND token_version = ?`
+	var user User
+
+	err := mdl.Db.QueryRowContext(ctx, query, id, tokenVersion).Scan(&user.Id,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.TokenVersion,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// RevokeJWTs invalidates every JWT issued to the user so far.
+func (mdl *UserModel) RevokeJWTs(ctx context.Context, id string) error {
+	query := `UPDATE users SET token_version = token_version + 1 WHERE id = ?`
+
+	_, err := mdl.Db.ExecContext(ctx, query, id)
+	return err
+}
+
 func (mdl *UserModel) UpdateUser(ctx context.Context, user *User) error {
 	var (
 		query = `UPDATE users SET name=?, email=?, password_hash=?, activated=? WHERE id = ?`
@@ -154,11 +207,38 @@ func (mdl *UserModel) UpdateUser(ctx context.Context, user *User) error {
 	return nil
 }
 
+func (mdl *UserModel) DeleteUser(ctx context.Context, id string) error {
+	tx, err := mdl.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM user_permissions WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return tx.Commit()
+}
+
 func (mdl *UserModel) GetForToken(ctx context.Context, scope, plainTxt string) (*User, error) {
 	var (
 		hash  = sha256.Sum256([]byte(plainTxt))
-		query = `SELECT u.id, u.created_at, u.name, u.email, u.password_hash, u.activated FROM users AS u 
-		    	   INNER JOIN tokens AS t ON u.id = t.user_id WHERE t.hash = ? AND t.scope = ? 
+		query = `SELECT u.id, u.created_at, u.name, u.email, u.password_hash, u.activated, u.token_version
+		    	   FROM users AS u INNER JOIN tokens AS t ON u.id = t.user_id WHERE t.hash = ? AND t.scope = ? 
 			   AND t.expiry > ?`
 
 		args = []any{hash[:], scope, time.Now()}
@@ -170,6 +250,7 @@ func (mdl *UserModel) GetForToken(ctx context.Context, scope, plainTxt string) (
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.TokenVersion,
 	)
 	if err != nil {
 		switch {
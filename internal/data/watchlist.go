@@ -0,0 +1,110 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type WatchlistModel struct {
+	Db *sql.DB
+}
+
+func (mdl *WatchlistModel) Add(ctx context.Context, userId string, movieId int64) error {
+	if movieId < 1 {
+		return ErrRecordNotFound
+	}
+	query := `INSERT INTO watchlist (user_id, movie_id)
+		    SELECT ?, id FROM movies WHERE id = ? AND deleted_at IS NULL`
+
+	res, err := mdl.Db.ExecContext(ctx, query, userId, movieId)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		switch {
+		case errors.As(err, &mysqlErr) && mysqlErr.Number == 1062:
+			return nil
+		case errors.As(err, &mysqlErr) && mysqlErr.Number == 1452:
+			return ErrUnknownUser
+		default:
+			return err
+		}
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (mdl *WatchlistModel) Remove(ctx context.Context, userId string, movieId int64) error {
+	if movieId < 1 {
+		return ErrRecordNotFound
+	}
+	res, err := mdl.Db.ExecContext(ctx, `DELETE FROM watchlist WHERE user_id = ? AND movie_id = ?`,
+		userId, movieId,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (mdl *WatchlistModel) GetAllForUser(ctx context.Context, userId string, ftr Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(
+		`SELECT COUNT(*) OVER(), m.id, m.created_at, m.updated_at, m.title, m.year, m.runtime, m.version, GROUP_CONCAT(DISTINCT g.name) 
+			  FROM watchlist AS w 
+			  INNER JOIN movies AS m ON m.id = w.movie_id
+			  LEFT JOIN movie_genres AS mg ON mg.movie_id = m.id
+            	  LEFT JOIN genres AS g ON g.id = mg.genre_id 
+			  WHERE w.user_id = ? AND m.deleted_at IS NULL
+			  GROUP BY m.id ORDER BY %s LIMIT ? OFFSET ?`, ftr.orderBy(),
+	)
+	args := []any{userId, ftr.limit(), ftr.offset()}
+
+	rows, err := mdl.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			slog.Error("Failed to close rows: ", "err", err)
+		}
+	}(rows)
+	var (
+		movies       []*Movie
+		genreList    string
+		metadata     Metadata
+		totalRecords int
+	)
+	for rows.Next() {
+		var m Movie
+		err := rows.Scan(&totalRecords, &m.Id, &m.CreatedAt, &m.UpdatedAt, &m.Title, &m.Year, &m.Runtime, &m.Version, &genreList)
+		if err != nil {
+			return nil, metadata, err
+		}
+		if genreList != "" {
+			m.Genres = strings.Split(genreList, ",")
+		}
+		movies = append(movies, &m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, metadata, err
+	}
+	metadata = calculateMetadata(totalRecords, ftr.Page, ftr.PageSize)
+	return movies, metadata, nil
+}
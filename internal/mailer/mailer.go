@@ -3,7 +3,9 @@ package mailer
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"html/template"
+	"net/textproto"
 	"time"
 
 	"github.com/go-mail/mail/v2"
@@ -13,17 +15,23 @@ import (
 var templateFS embed.FS
 
 type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+	dialer     *mail.Dialer
+	sender     string
+	retries    int
+	retryDelay time.Duration
 }
 
-func NewMailer(host string, port int, username, password, sender string) Mailer {
+func NewMailer(host string, port int, username, password, sender string, retries int,
+	retryDelay time.Duration,
+) Mailer {
 	dialer := mail.NewDialer(host, port, username, password)
 	dialer.Timeout = 5 * time.Second
 
 	return Mailer{
-		dialer: dialer,
-		sender: sender,
+		dialer:     dialer,
+		sender:     sender,
+		retries:    max(retries, 0),
+		retryDelay: retryDelay,
 	}
 }
 
@@ -55,12 +63,22 @@ func (mlr *Mailer) SendEmail(recipient, tmpltName string, data any) error {
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
 
-	for i := 0; i < 3; i++ {
+	delay := mlr.retryDelay
+	for attempt := 0; ; attempt++ {
 		err = mlr.dialer.DialAndSend(msg)
-		if nil == err {
-			return nil
+		if err == nil || isPermanent(err) || attempt >= mlr.retries {
+			return err
 		}
-		time.Sleep(1 * time.Second)
+		time.Sleep(delay)
+		delay *= 2
 	}
-	return err
+}
+
+func isPermanent(err error) bool {
+	var sendErr *mail.SendError
+	if errors.As(err, &sendErr) {
+		err = sendErr.Cause
+	}
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
 }
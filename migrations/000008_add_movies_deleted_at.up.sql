@@ -0,0 +1,2 @@
+ALTER TABLE movies
+    ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
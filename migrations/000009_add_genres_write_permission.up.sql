@@ -0,0 +1,2 @@
+INSERT INTO permissions (code)
+VALUES ('genres:write');
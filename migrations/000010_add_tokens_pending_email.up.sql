@@ -0,0 +1,2 @@
+ALTER TABLE tokens
+    ADD COLUMN pending_email VARCHAR(50) NULL DEFAULT NULL;
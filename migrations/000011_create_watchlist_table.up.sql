@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS watchlist
+(
+    user_id    VARCHAR(36) NOT NULL,
+    movie_id   BIGINT      NOT NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, movie_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (movie_id) REFERENCES movies (id) ON DELETE CASCADE
+);
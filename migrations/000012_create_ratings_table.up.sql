@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS ratings
+(
+    user_id    VARCHAR(36) NOT NULL,
+    movie_id   BIGINT      NOT NULL,
+    score      TINYINT     NOT NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, movie_id),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (movie_id) REFERENCES movies (id) ON DELETE CASCADE,
+    CONSTRAINT ratings_score_check CHECK ( score BETWEEN 1 AND 5 )
+);
+
+CREATE INDEX idx_ratings_movie ON ratings (movie_id);
@@ -0,0 +1,2 @@
+ALTER TABLE movies
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
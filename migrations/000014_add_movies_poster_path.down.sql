@@ -0,0 +1 @@
+ALTER TABLE movies DROP COLUMN poster_path;
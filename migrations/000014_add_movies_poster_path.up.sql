@@ -0,0 +1,2 @@
+ALTER TABLE movies
+    ADD COLUMN poster_path VARCHAR(255) NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN token_version INT NOT NULL DEFAULT 1;